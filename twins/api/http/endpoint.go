@@ -0,0 +1,101 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/mainflux/mainflux/twins"
+)
+
+func listStatesEndpoint(svc twins.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listStatesReq)
+
+		page, err := svc.ListStates(ctx, req.token, req.offset, req.limit, req.id, req.filter)
+		if err != nil {
+			return nil, err
+		}
+
+		return statesPageRes{page}, nil
+	}
+}
+
+func listDefinitionsEndpoint(svc twins.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listDefinitionsReq)
+
+		page, err := svc.ListDefinitions(ctx, req.token, req.id, req.offset, req.limit)
+		if err != nil {
+			return nil, err
+		}
+
+		return definitionsPageRes{page}, nil
+	}
+}
+
+func viewDefinitionEndpoint(svc twins.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(viewDefinitionReq)
+
+		def, err := svc.ViewDefinition(ctx, req.token, req.id, req.defID)
+		if err != nil {
+			return nil, err
+		}
+
+		return definitionRes{def}, nil
+	}
+}
+
+func rollbackDefinitionEndpoint(svc twins.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(viewDefinitionReq)
+
+		tw, err := svc.RollbackDefinition(ctx, req.token, req.id, req.defID)
+		if err != nil {
+			return nil, err
+		}
+
+		return twinRes{tw}, nil
+	}
+}
+
+func createSubscriptionEndpoint(svc twins.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(createSubscriptionReq)
+
+		sub, err := svc.CreateSubscription(ctx, req.token, req.id, req.EventType, req.URL, req.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		return subscriptionRes{sub}, nil
+	}
+}
+
+func listSubscriptionsEndpoint(svc twins.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listDefinitionsReq)
+
+		page, err := svc.ListSubscriptions(ctx, req.token, req.id, req.offset, req.limit)
+		if err != nil {
+			return nil, err
+		}
+
+		return subscriptionsPageRes{page}, nil
+	}
+}
+
+func removeSubscriptionEndpoint(svc twins.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(removeSubscriptionReq)
+
+		if err := svc.RemoveSubscription(ctx, req.token, req.id, req.subID); err != nil {
+			return nil, err
+		}
+
+		return removedRes{}, nil
+	}
+}
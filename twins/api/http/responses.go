@@ -0,0 +1,70 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/mainflux/mainflux/twins"
+)
+
+// statesPageRes mirrors twins.StatesPage: either raw States or, when the
+// request asked for aggregation, per-attribute Aggregates is populated
+// instead.
+type statesPageRes struct {
+	twins.StatesPage
+}
+
+type definitionsPageRes struct {
+	twins.DefinitionsPage
+}
+
+type definitionRes struct {
+	twins.Definition
+}
+
+type twinRes struct {
+	twins.Twin
+}
+
+type subscriptionRes struct {
+	twins.Subscription
+}
+
+// subscriptionsPageRes mirrors twins.SubscriptionsPage but redacts each
+// Subscription's HMAC Secret: listing is the only way another request on
+// the same twin could read back a webhook signing secret it didn't set,
+// so it must never appear in a list/view response.
+type subscriptionsPageRes struct {
+	twins.SubscriptionsPage
+}
+
+func (spr subscriptionsPageRes) MarshalJSON() ([]byte, error) {
+	redacted := make([]twins.Subscription, len(spr.Subscriptions))
+	for i, s := range spr.Subscriptions {
+		s.Secret = ""
+		redacted[i] = s
+	}
+
+	return json.Marshal(struct {
+		Total         uint64               `json:"total"`
+		Offset        uint64               `json:"offset"`
+		Limit         uint64               `json:"limit"`
+		Subscriptions []twins.Subscription `json:"subscriptions"`
+	}{
+		Total:         spr.Total,
+		Offset:        spr.Offset,
+		Limit:         spr.Limit,
+		Subscriptions: redacted,
+	})
+}
+
+type removedRes struct{}
+
+func encodeResponse(_ context.Context, w http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,281 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/mainflux/mainflux/twins"
+)
+
+// MakeHandler returns a HTTP handler for the twins service states,
+// definitions and subscriptions API.
+func MakeHandler(svc twins.Service) http.Handler {
+	r := mux.NewRouter()
+
+	opts := []kithttp.ServerOption{
+		kithttp.ServerErrorEncoder(encodeError),
+	}
+
+	r.Handle("/twins/{id}/states", kithttp.NewServer(
+		listStatesEndpoint(svc),
+		decodeListStates,
+		encodeResponse,
+		opts...,
+	)).Methods(http.MethodGet)
+
+	r.Handle("/twins/{id}/definitions", kithttp.NewServer(
+		listDefinitionsEndpoint(svc),
+		decodeListDefinitions,
+		encodeResponse,
+		opts...,
+	)).Methods(http.MethodGet)
+
+	r.Handle("/twins/{id}/definitions/{defID}", kithttp.NewServer(
+		viewDefinitionEndpoint(svc),
+		decodeViewDefinition,
+		encodeResponse,
+		opts...,
+	)).Methods(http.MethodGet)
+
+	r.Handle("/twins/{id}/definitions/{defID}/rollback", kithttp.NewServer(
+		rollbackDefinitionEndpoint(svc),
+		decodeViewDefinition,
+		encodeResponse,
+		opts...,
+	)).Methods(http.MethodPost)
+
+	r.Handle("/twins/{id}/subscriptions", kithttp.NewServer(
+		createSubscriptionEndpoint(svc),
+		decodeCreateSubscription,
+		encodeResponse,
+		opts...,
+	)).Methods(http.MethodPost)
+
+	r.Handle("/twins/{id}/subscriptions", kithttp.NewServer(
+		listSubscriptionsEndpoint(svc),
+		decodeListDefinitions,
+		encodeResponse,
+		opts...,
+	)).Methods(http.MethodGet)
+
+	r.Handle("/twins/{id}/subscriptions/{subID}", kithttp.NewServer(
+		removeSubscriptionEndpoint(svc),
+		decodeRemoveSubscription,
+		encodeResponse,
+		opts...,
+	)).Methods(http.MethodDelete)
+
+	return r
+}
+
+// encodeError maps twins' sentinel domain errors to their HTTP status codes;
+// without it go-kit's DefaultErrorEncoder would report every error,
+// including unauthorized/not-found/malformed-entity, as a 500.
+func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+	var status int
+	switch {
+	case errors.Is(err, twins.ErrUnauthorizedAccess):
+		status = http.StatusUnauthorized
+	case errors.Is(err, twins.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, twins.ErrMalformedEntity):
+		status = http.StatusBadRequest
+	default:
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+type listStatesReq struct {
+	token  string
+	id     string
+	offset uint64
+	limit  uint64
+	filter twins.StateFilter
+}
+
+// decodeListStates parses offset/limit plus the from/to/definition/attribute
+// and aggregation query parameters, pushing filtering work down to the
+// repository instead of paging through everything client-side.
+func decodeListStates(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	offset, err := readUint(r, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := readUint(r, "limit", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := readInt(r, "from", 0)
+	if err != nil {
+		return nil, err
+	}
+	to, err := readInt(r, "to", 0)
+	if err != nil {
+		return nil, err
+	}
+	def, err := readInt(r, "definition", -1)
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs []string
+	if q := r.URL.Query().Get("attribute"); q != "" {
+		attrs = strings.Split(q, ",")
+	}
+
+	var aggs []twins.Aggregation
+	if q := r.URL.Query().Get("aggregation"); q != "" {
+		for _, a := range strings.Split(q, ",") {
+			aggs = append(aggs, twins.Aggregation(a))
+		}
+	}
+
+	bucket := 0 * time.Second
+	if q := r.URL.Query().Get("bucket_size"); q != "" {
+		d, err := time.ParseDuration(q)
+		if err != nil {
+			return nil, twins.ErrMalformedEntity
+		}
+		bucket = d
+	}
+
+	req := listStatesReq{
+		token:  r.Header.Get("Authorization"),
+		id:     vars["id"],
+		offset: offset,
+		limit:  limit,
+		filter: twins.StateFilter{
+			From:         from,
+			To:           to,
+			Definition:   def,
+			Attributes:   attrs,
+			Aggregations: aggs,
+			BucketSize:   bucket,
+		},
+	}
+
+	return req, nil
+}
+
+type listDefinitionsReq struct {
+	token  string
+	id     string
+	offset uint64
+	limit  uint64
+}
+
+func decodeListDefinitions(_ context.Context, r *http.Request) (interface{}, error) {
+	offset, err := readUint(r, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := readUint(r, "limit", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	return listDefinitionsReq{
+		token:  r.Header.Get("Authorization"),
+		id:     mux.Vars(r)["id"],
+		offset: offset,
+		limit:  limit,
+	}, nil
+}
+
+type viewDefinitionReq struct {
+	token string
+	id    string
+	defID int
+}
+
+func decodeViewDefinition(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	defID, err := strconv.Atoi(vars["defID"])
+	if err != nil {
+		return nil, twins.ErrMalformedEntity
+	}
+
+	return viewDefinitionReq{
+		token: r.Header.Get("Authorization"),
+		id:    vars["id"],
+		defID: defID,
+	}, nil
+}
+
+type createSubscriptionReq struct {
+	token     string
+	id        string
+	EventType string `json:"event_type"`
+	URL       string `json:"url"`
+	Secret    string `json:"secret"`
+}
+
+func decodeCreateSubscription(_ context.Context, r *http.Request) (interface{}, error) {
+	req := createSubscriptionReq{
+		token: r.Header.Get("Authorization"),
+		id:    mux.Vars(r)["id"],
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, twins.ErrMalformedEntity
+	}
+
+	return req, nil
+}
+
+type removeSubscriptionReq struct {
+	token string
+	id    string
+	subID string
+}
+
+func decodeRemoveSubscription(_ context.Context, r *http.Request) (interface{}, error) {
+	vars := mux.Vars(r)
+
+	return removeSubscriptionReq{
+		token: r.Header.Get("Authorization"),
+		id:    vars["id"],
+		subID: vars["subID"],
+	}, nil
+}
+
+func readUint(r *http.Request, name string, def uint64) (uint64, error) {
+	q := r.URL.Query().Get(name)
+	if q == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseUint(q, 10, 64)
+	if err != nil {
+		return 0, twins.ErrMalformedEntity
+	}
+	return v, nil
+}
+
+func readInt(r *http.Request, name string, def int64) (int64, error) {
+	q := r.URL.Query().Get(name)
+	if q == "" {
+		return def, nil
+	}
+	v, err := strconv.ParseInt(q, 10, 64)
+	if err != nil {
+		return 0, twins.ErrMalformedEntity
+	}
+	return v, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/mainflux/mainflux"
+	"github.com/mainflux/mainflux/twins"
+)
+
+var _ twins.Service = (*metricsMiddleware)(nil)
+
+type metricsMiddleware struct {
+	counter      metrics.Counter
+	latency      metrics.Histogram
+	saveTimeouts metrics.Counter
+	svc          twins.Service
+}
+
+func isDeadlineExceeded(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// MetricsMiddleware instruments a twins service by tracking request count
+// and latency for every operation, plus how often SaveState misses its
+// per-message save deadline.
+func MetricsMiddleware(svc twins.Service, counter, saveTimeouts metrics.Counter, latency metrics.Histogram) twins.Service {
+	return &metricsMiddleware{
+		counter:      counter,
+		latency:      latency,
+		saveTimeouts: saveTimeouts,
+		svc:          svc,
+	}
+}
+
+func (mm *metricsMiddleware) observe(method string, begin time.Time) {
+	mm.counter.With("method", method).Add(1)
+	mm.latency.With("method", method).Observe(time.Since(begin).Seconds())
+}
+
+func (mm *metricsMiddleware) AddTwin(ctx context.Context, token string, twin twins.Twin, def twins.Definition) (twins.Twin, error) {
+	defer mm.observe("add_twin", time.Now())
+	return mm.svc.AddTwin(ctx, token, twin, def)
+}
+
+func (mm *metricsMiddleware) UpdateTwin(ctx context.Context, token string, twin twins.Twin, def twins.Definition) error {
+	defer mm.observe("update_twin", time.Now())
+	return mm.svc.UpdateTwin(ctx, token, twin, def)
+}
+
+func (mm *metricsMiddleware) ViewTwin(ctx context.Context, token, id string) (twins.Twin, error) {
+	defer mm.observe("view_twin", time.Now())
+	return mm.svc.ViewTwin(ctx, token, id)
+}
+
+func (mm *metricsMiddleware) ListTwins(ctx context.Context, token string, offset, limit uint64, name string, metadata twins.Metadata) (twins.TwinsPage, error) {
+	defer mm.observe("list_twins", time.Now())
+	return mm.svc.ListTwins(ctx, token, offset, limit, name, metadata)
+}
+
+func (mm *metricsMiddleware) ListStates(ctx context.Context, token string, offset, limit uint64, id string, sf twins.StateFilter) (twins.StatesPage, error) {
+	defer mm.observe("list_states", time.Now())
+	return mm.svc.ListStates(ctx, token, offset, limit, id, sf)
+}
+
+// SaveState is on the hot NATS consumption path: besides the usual
+// count/latency pair, it records a saveTimeouts hit whenever the call was
+// cut short by the service's per-message deadline, so operators can see
+// backpressure building up in storage.
+func (mm *metricsMiddleware) SaveState(msg *mainflux.Message) (err error) {
+	defer func(begin time.Time) {
+		mm.observe("save_state", begin)
+		if isDeadlineExceeded(err) {
+			mm.saveTimeouts.With("method", "save_state").Add(1)
+		}
+	}(time.Now())
+
+	return mm.svc.SaveState(msg)
+}
+
+func (mm *metricsMiddleware) ViewTwinByThing(ctx context.Context, token, thingID string) (twins.Twin, error) {
+	defer mm.observe("view_twin_by_thing", time.Now())
+	return mm.svc.ViewTwinByThing(ctx, token, thingID)
+}
+
+func (mm *metricsMiddleware) RemoveTwin(ctx context.Context, token, id string) error {
+	defer mm.observe("remove_twin", time.Now())
+	return mm.svc.RemoveTwin(ctx, token, id)
+}
+
+func (mm *metricsMiddleware) ListDefinitions(ctx context.Context, token, twinID string, offset, limit uint64) (twins.DefinitionsPage, error) {
+	defer mm.observe("list_definitions", time.Now())
+	return mm.svc.ListDefinitions(ctx, token, twinID, offset, limit)
+}
+
+func (mm *metricsMiddleware) ViewDefinition(ctx context.Context, token, twinID string, defID int) (twins.Definition, error) {
+	defer mm.observe("view_definition", time.Now())
+	return mm.svc.ViewDefinition(ctx, token, twinID, defID)
+}
+
+func (mm *metricsMiddleware) RollbackDefinition(ctx context.Context, token, twinID string, defID int) (twins.Twin, error) {
+	defer mm.observe("rollback_definition", time.Now())
+	return mm.svc.RollbackDefinition(ctx, token, twinID, defID)
+}
+
+func (mm *metricsMiddleware) CreateSubscription(ctx context.Context, token, twinID, eventType, url, secret string) (twins.Subscription, error) {
+	defer mm.observe("create_subscription", time.Now())
+	return mm.svc.CreateSubscription(ctx, token, twinID, eventType, url, secret)
+}
+
+func (mm *metricsMiddleware) ListSubscriptions(ctx context.Context, token, twinID string, offset, limit uint64) (twins.SubscriptionsPage, error) {
+	defer mm.observe("list_subscriptions", time.Now())
+	return mm.svc.ListSubscriptions(ctx, token, twinID, offset, limit)
+}
+
+func (mm *metricsMiddleware) RemoveSubscription(ctx context.Context, token, twinID, subID string) error {
+	defer mm.observe("remove_subscription", time.Now())
+	return mm.svc.RemoveSubscription(ctx, token, twinID, subID)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+
+	"github.com/mainflux/mainflux"
+	"github.com/mainflux/mainflux/twins"
+	"google.golang.org/grpc"
+)
+
+var _ mainflux.AuthNServiceClient = (*authServiceMock)(nil)
+
+type authServiceMock struct {
+	users map[string]string
+}
+
+// NewAuthService creates mock of authn service, used in tests, that maps
+// tokens to owner IDs it was seeded with.
+func NewAuthService(users map[string]string) mainflux.AuthNServiceClient {
+	return &authServiceMock{users: users}
+}
+
+func (svc *authServiceMock) Identify(_ context.Context, in *mainflux.Token, _ ...grpc.CallOption) (*mainflux.UserIdentity, error) {
+	if id, ok := svc.users[in.GetValue()]; ok {
+		return &mainflux.UserIdentity{Value: id}, nil
+	}
+
+	return nil, twins.ErrUnauthorizedAccess
+}
+
+func (svc *authServiceMock) Issue(_ context.Context, in *mainflux.IssueReq, _ ...grpc.CallOption) (*mainflux.Token, error) {
+	if id, ok := svc.users[in.GetEmail()]; ok {
+		return &mainflux.Token{Value: id}, nil
+	}
+
+	return nil, twins.ErrUnauthorizedAccess
+}
@@ -0,0 +1,35 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/gofrs/uuid"
+	"github.com/mainflux/mainflux/twins"
+)
+
+var _ twins.IdentityProvider = (*uuidIdentityProvider)(nil)
+
+type uuidIdentityProvider struct {
+	mu sync.Mutex
+}
+
+// NewIdentityProvider creates "mirror" identity provider, i.e. generator
+// of random UUIDs, to be used in tests.
+func NewIdentityProvider() twins.IdentityProvider {
+	return &uuidIdentityProvider{}
+}
+
+func (idp *uuidIdentityProvider) ID() (string, error) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
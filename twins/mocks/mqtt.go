@@ -0,0 +1,41 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"sync"
+
+	"github.com/mainflux/mainflux/twins/mqtt"
+)
+
+var _ mqtt.Mqtt = (*mqttMock)(nil)
+
+type published struct {
+	ID      string
+	Op      string
+	Payload []byte
+}
+
+type mqttMock struct {
+	mu        sync.Mutex
+	published []published
+}
+
+// NewMqttClient creates a no-op MQTT client that records every event it
+// was asked to publish, for assertions in tests.
+func NewMqttClient() mqtt.Mqtt {
+	return &mqttMock{}
+}
+
+func (m *mqttMock) Publish(id *string, err *error, succOp, failOp string, payload *[]byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op := succOp
+	if *err != nil {
+		op = failOp
+	}
+
+	m.published = append(m.published, published{ID: *id, Op: op, Payload: *payload})
+}
@@ -0,0 +1,176 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/mainflux/mainflux/twins"
+)
+
+var _ twins.StateRepository = (*stateRepositoryMock)(nil)
+
+type stateRepositoryMock struct {
+	mu     sync.Mutex
+	states map[string][]twins.State
+}
+
+// NewStateRepository creates in-memory state repository used for tests.
+func NewStateRepository() twins.StateRepository {
+	return &stateRepositoryMock{
+		states: make(map[string][]twins.State),
+	}
+}
+
+func (srm *stateRepositoryMock) Save(_ context.Context, st twins.State) error {
+	srm.mu.Lock()
+	defer srm.mu.Unlock()
+
+	srm.states[st.TwinID] = append(srm.states[st.TwinID], st)
+	return nil
+}
+
+func (srm *stateRepositoryMock) RetrieveLast(_ context.Context, twinID string) (twins.State, error) {
+	srm.mu.Lock()
+	defer srm.mu.Unlock()
+
+	sts := srm.states[twinID]
+	if len(sts) == 0 {
+		return twins.State{TwinID: twinID}, nil
+	}
+
+	return sts[len(sts)-1], nil
+}
+
+func (srm *stateRepositoryMock) RetrieveAll(_ context.Context, offset, limit uint64, twinID string, sf twins.StateFilter) (twins.StatesPage, error) {
+	srm.mu.Lock()
+	defer srm.mu.Unlock()
+
+	var matched []twins.State
+	for _, st := range srm.states[twinID] {
+		if sf.From > 0 && st.Created.UnixNano() < sf.From {
+			continue
+		}
+		if sf.To > 0 && st.Created.UnixNano() >= sf.To {
+			continue
+		}
+		if sf.Definition >= 0 && st.Definition != sf.Definition {
+			continue
+		}
+		matched = append(matched, withFilteredPayload(st, sf.Attributes))
+	}
+
+	if len(sf.Aggregations) > 0 {
+		return twins.StatesPage{Aggregates: aggregate(matched, sf)}, nil
+	}
+
+	total := uint64(len(matched))
+	if offset >= total {
+		return twins.StatesPage{Total: total, Offset: offset, Limit: limit}, nil
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return twins.StatesPage{
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+		States: matched[offset:end],
+	}, nil
+}
+
+// withFilteredPayload returns a copy of st whose Payload is trimmed down to
+// attrs, mirroring postgres.stateRepository.RetrieveAll so tests exercise
+// the same attribute-filtering contract the real repository honors.
+func withFilteredPayload(st twins.State, attrs []string) twins.State {
+	if len(attrs) == 0 {
+		return st
+	}
+
+	keep := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		keep[a] = true
+	}
+
+	filtered := make(map[string]interface{}, len(attrs))
+	for k, v := range st.Payload {
+		if keep[k] {
+			filtered[k] = v
+		}
+	}
+	st.Payload = filtered
+
+	return st
+}
+
+// aggregate computes the requested subset of min/max/avg/sum/count per
+// numeric attribute across a single bucket spanning all of states, which is
+// enough to exercise StateFilter.Aggregations in tests without replicating
+// postgres's bucket_start windowing.
+func aggregate(states []twins.State, sf twins.StateFilter) []twins.AttributeAggregate {
+	requested := make(map[twins.Aggregation]bool, len(sf.Aggregations))
+	for _, a := range sf.Aggregations {
+		requested[a] = true
+	}
+
+	sums := make(map[string][]float64)
+	for _, st := range states {
+		for k, v := range st.Payload {
+			p, ok := v.(*float64)
+			if !ok || p == nil {
+				continue
+			}
+			sums[k] = append(sums[k], *p)
+		}
+	}
+
+	var aggs []twins.AttributeAggregate
+	for attr, values := range sums {
+		a := twins.AttributeAggregate{Attribute: attr}
+		if requested[twins.AggCount] {
+			a.Count = uint64(len(values))
+		}
+		var sum float64
+		min, max := values[0], values[0]
+		for _, v := range values {
+			sum += v
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		if requested[twins.AggSum] {
+			a.Sum = sum
+		}
+		if requested[twins.AggAvg] {
+			a.Avg = sum / float64(len(values))
+		}
+		if requested[twins.AggMin] {
+			a.Min = min
+		}
+		if requested[twins.AggMax] {
+			a.Max = max
+		}
+		aggs = append(aggs, a)
+	}
+
+	sort.Slice(aggs, func(i, j int) bool { return aggs[i].Attribute < aggs[j].Attribute })
+
+	return aggs
+}
+
+// AllStates exposes the stored states for a twin directly, for assertions
+// in tests that don't want to go through RetrieveAll pagination.
+func (srm *stateRepositoryMock) AllStates(twinID string) []twins.State {
+	srm.mu.Lock()
+	defer srm.mu.Unlock()
+
+	return srm.states[twinID]
+}
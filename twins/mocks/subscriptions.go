@@ -0,0 +1,70 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mainflux/mainflux/twins"
+)
+
+var _ twins.SubscriptionRepository = (*subscriptionRepositoryMock)(nil)
+
+type subscriptionRepositoryMock struct {
+	mu   sync.Mutex
+	subs map[string]twins.Subscription
+}
+
+// NewSubscriptionRepository creates in-memory subscription repository
+// used for tests.
+func NewSubscriptionRepository() twins.SubscriptionRepository {
+	return &subscriptionRepositoryMock{
+		subs: make(map[string]twins.Subscription),
+	}
+}
+
+func (srm *subscriptionRepositoryMock) Save(_ context.Context, sub twins.Subscription) (string, error) {
+	srm.mu.Lock()
+	defer srm.mu.Unlock()
+
+	srm.subs[sub.ID] = sub
+	return sub.ID, nil
+}
+
+func (srm *subscriptionRepositoryMock) RetrieveByTwin(_ context.Context, twinID string) ([]twins.Subscription, error) {
+	srm.mu.Lock()
+	defer srm.mu.Unlock()
+
+	var subs []twins.Subscription
+	for _, s := range srm.subs {
+		if s.TwinID == twinID {
+			subs = append(subs, s)
+		}
+	}
+
+	return subs, nil
+}
+
+func (srm *subscriptionRepositoryMock) RetrieveAll(_ context.Context, twinID string, offset, limit uint64) (twins.SubscriptionsPage, error) {
+	subs, err := srm.RetrieveByTwin(context.Background(), twinID)
+	if err != nil {
+		return twins.SubscriptionsPage{}, err
+	}
+
+	return twins.SubscriptionsPage{
+		Total:         uint64(len(subs)),
+		Offset:        offset,
+		Limit:         limit,
+		Subscriptions: subs,
+	}, nil
+}
+
+func (srm *subscriptionRepositoryMock) Remove(_ context.Context, id string) error {
+	srm.mu.Lock()
+	defer srm.mu.Unlock()
+
+	delete(srm.subs, id)
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mainflux/mainflux/twins"
+)
+
+var _ twins.TwinRepository = (*twinRepositoryMock)(nil)
+
+type twinRepositoryMock struct {
+	mu    sync.Mutex
+	twins map[string]twins.Twin
+}
+
+// NewTwinRepository creates in-memory twin repository used for tests.
+func NewTwinRepository() twins.TwinRepository {
+	return &twinRepositoryMock{
+		twins: make(map[string]twins.Twin),
+	}
+}
+
+func (trm *twinRepositoryMock) Save(_ context.Context, tw twins.Twin) (string, error) {
+	trm.mu.Lock()
+	defer trm.mu.Unlock()
+
+	if _, ok := trm.twins[tw.ID]; ok {
+		return "", twins.ErrConflict
+	}
+
+	trm.twins[tw.ID] = tw
+	return tw.ID, nil
+}
+
+func (trm *twinRepositoryMock) Update(_ context.Context, tw twins.Twin) error {
+	trm.mu.Lock()
+	defer trm.mu.Unlock()
+
+	if _, ok := trm.twins[tw.ID]; !ok {
+		return twins.ErrNotFound
+	}
+
+	trm.twins[tw.ID] = tw
+	return nil
+}
+
+func (trm *twinRepositoryMock) RetrieveByID(_ context.Context, twinID string) (twins.Twin, error) {
+	trm.mu.Lock()
+	defer trm.mu.Unlock()
+
+	tw, ok := trm.twins[twinID]
+	if !ok {
+		return twins.Twin{}, twins.ErrNotFound
+	}
+
+	return tw, nil
+}
+
+func (trm *twinRepositoryMock) RetrieveByThing(_ context.Context, thingID string) (twins.Twin, error) {
+	trm.mu.Lock()
+	defer trm.mu.Unlock()
+
+	for _, tw := range trm.twins {
+		if tw.ThingID == thingID {
+			return tw, nil
+		}
+	}
+
+	return twins.Twin{}, twins.ErrNotFound
+}
+
+func (trm *twinRepositoryMock) RetrieveAll(_ context.Context, owner string, offset, limit uint64, name string, metadata twins.Metadata) (twins.TwinsPage, error) {
+	return twins.TwinsPage{}, nil
+}
+
+func (trm *twinRepositoryMock) Remove(_ context.Context, twinID string) error {
+	trm.mu.Lock()
+	defer trm.mu.Unlock()
+
+	delete(trm.twins, twinID)
+	return nil
+}
@@ -0,0 +1,14 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package mqtt
+
+// Mqtt publishes twin CRUD and state events over MQTT so external
+// subscribers can observe them without going through the HTTP API.
+type Mqtt interface {
+	// Publish sends a success or failure event for the entity identified by
+	// id, depending on whether *err is nil, carrying payload as the event
+	// body. It is meant to be called via defer, right after the pointers
+	// it receives have been populated by the calling operation.
+	Publish(id *string, err *error, succOp, failOp string, payload *[]byte)
+}
@@ -0,0 +1,45 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package twins
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/mainflux/mainflux"
+	broker "github.com/nats-io/go-nats"
+)
+
+const (
+	// SubjectsWildcard is the subject twins subscribes to in order to
+	// receive every message published by things over the message bus.
+	SubjectsWildcard = "channel.>"
+	queue            = "twins"
+)
+
+// Subscribe starts consuming messages from the NATS message bus and
+// forwards each one to svc.SaveState. The returned subscription is torn
+// down as soon as ctx is cancelled, so callers can stop the consumer
+// cleanly at shutdown instead of leaving it running against a closed
+// connection.
+func Subscribe(ctx context.Context, nc *broker.Conn, svc Service) (*broker.Subscription, error) {
+	sub, err := nc.QueueSubscribe(SubjectsWildcard, queue, func(m *broker.Msg) {
+		var msg mainflux.Message
+		if err := proto.Unmarshal(m.Data, &msg); err != nil {
+			return
+		}
+
+		svc.SaveState(&msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		sub.Unsubscribe()
+	}()
+
+	return sub, nil
+}
@@ -0,0 +1,27 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package twins
+
+// Notifier is implemented by anything that can be told about a twin CRUD
+// or state event. The MQTT client and the HTTP webhook notifier both
+// implement it so AddTwin/UpdateTwin/RemoveTwin/SaveState can fan events
+// out to every configured channel instead of being hard-wired to MQTT.
+type Notifier interface {
+	// Publish is called via defer right after the pointers it receives
+	// have been populated by the calling operation: id and payload carry
+	// the event subject and body, err determines whether succOp or failOp
+	// is reported.
+	Publish(id *string, err *error, succOp, failOp string, payload *[]byte)
+}
+
+// NotifierSet fans a single event out to every Notifier it holds. It
+// implements Notifier itself, so it can be used as a drop-in replacement
+// for a single notifier wherever one was expected.
+type NotifierSet []Notifier
+
+func (ns NotifierSet) Publish(id *string, err *error, succOp, failOp string, payload *[]byte) {
+	for _, n := range ns {
+		n.Publish(id, err, succOp, failOp, payload)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mainflux/mainflux/twins"
+)
+
+var _ twins.StateRepository = (*stateRepository)(nil)
+
+type stateRepository struct {
+	db *sqlx.DB
+}
+
+// NewStateRepository instantiates a PostgreSQL implementation of state
+// repository.
+func NewStateRepository(db *sqlx.DB) twins.StateRepository {
+	return &stateRepository{db: db}
+}
+
+func (sr stateRepository) Save(ctx context.Context, st twins.State) error {
+	q := `INSERT INTO states (id, twin_id, owner, definition, created, payload)
+	      VALUES (:id, :twin_id, :owner, :definition, :created, :payload)`
+
+	dbSt, err := toDBState(st)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sr.db.NamedExecContext(ctx, q, dbSt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (sr stateRepository) RetrieveLast(ctx context.Context, twinID string) (twins.State, error) {
+	q := `SELECT id, twin_id, owner, definition, created, payload FROM states
+	      WHERE twin_id = $1 ORDER BY id DESC LIMIT 1`
+
+	dbSt := dbState{TwinID: twinID}
+	if err := sr.db.QueryRowxContext(ctx, q, twinID).StructScan(&dbSt); err != nil {
+		if err == sql.ErrNoRows {
+			return twins.State{TwinID: twinID}, nil
+		}
+		return twins.State{}, err
+	}
+
+	return toState(dbSt)
+}
+
+func (sr stateRepository) RetrieveAll(ctx context.Context, offset, limit uint64, twinID string, sf twins.StateFilter) (twins.StatesPage, error) {
+	whereClause, params := buildStateFilter(twinID, sf)
+
+	if len(sf.Aggregations) > 0 {
+		return sr.retrieveAggregates(ctx, whereClause, params, sf)
+	}
+
+	q := fmt.Sprintf(`SELECT id, twin_id, owner, definition, created, payload FROM states
+	      %s ORDER BY created DESC LIMIT :limit OFFSET :offset`, whereClause)
+	cq := fmt.Sprintf(`SELECT COUNT(*) FROM states %s`, whereClause)
+
+	params["limit"] = limit
+	params["offset"] = offset
+
+	rows, err := sr.db.NamedQueryContext(ctx, q, params)
+	if err != nil {
+		return twins.StatesPage{}, err
+	}
+	defer rows.Close()
+
+	var states []twins.State
+	for rows.Next() {
+		var dbSt dbState
+		if err := rows.StructScan(&dbSt); err != nil {
+			return twins.StatesPage{}, err
+		}
+		st, err := toState(dbSt)
+		if err != nil {
+			return twins.StatesPage{}, err
+		}
+		filterPayload(&st, sf.Attributes)
+		states = append(states, st)
+	}
+
+	total, err := sr.total(ctx, cq, params)
+	if err != nil {
+		return twins.StatesPage{}, err
+	}
+
+	return twins.StatesPage{
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+		States: states,
+	}, nil
+}
+
+// filterPayload trims st.Payload down to the attrs named in sf.Attributes;
+// an empty attrs leaves the payload untouched. retrieveAggregates applies
+// the same filter to attr.key at the SQL level, but the raw-states path has
+// no per-attribute rows to filter there, so it's done once the payload is
+// back in Go.
+func filterPayload(st *twins.State, attrs []string) {
+	if len(attrs) == 0 {
+		return
+	}
+
+	keep := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		keep[a] = true
+	}
+
+	filtered := make(map[string]interface{}, len(attrs))
+	for k, v := range st.Payload {
+		if keep[k] {
+			filtered[k] = v
+		}
+	}
+	st.Payload = filtered
+}
+
+// retrieveAggregates computes the requested subset of min/max/avg/sum/count
+// per attribute over fixed-size buckets, pushing the bucketing down into the
+// database by flooring each state's epoch nanoseconds to the bucket width so the whole
+// aggregation happens server-side. Only attribute values that look numeric
+// are cast and aggregated, since jsonb_each_text also surfaces "string"/
+// "bool"-typed attributes that a ::float8 cast would otherwise choke on.
+func (sr stateRepository) retrieveAggregates(ctx context.Context, whereClause string, params map[string]interface{}, sf twins.StateFilter) (twins.StatesPage, error) {
+	bucketNanos := sf.BucketSize.Nanoseconds()
+	if bucketNanos <= 0 {
+		bucketNanos = time.Hour.Nanoseconds()
+	}
+	params["bucket"] = bucketNanos
+
+	q := fmt.Sprintf(`SELECT
+	        attr.key AS attribute,
+	        to_timestamp(floor(extract(epoch from created) * 1e9 / :bucket) * :bucket / 1e9) AS bucket_start,
+	        %s
+	      FROM states, jsonb_each_text(payload) AS attr(key, value)
+	      %s
+	      AND attr.value ~ '^-?[0-9]+(\.[0-9]+)?$'
+	      GROUP BY attr.key, bucket_start
+	      ORDER BY bucket_start ASC`, aggregateColumns(sf.Aggregations), withAttributeFilter(whereClause, sf.Attributes, params))
+
+	rows, err := sr.db.NamedQueryContext(ctx, q, params)
+	if err != nil {
+		return twins.StatesPage{}, err
+	}
+	defer rows.Close()
+
+	var aggs []twins.AttributeAggregate
+	for rows.Next() {
+		var (
+			a                  twins.AttributeAggregate
+			min, max, avg, sum sql.NullFloat64
+			count              sql.NullInt64
+		)
+		if err := rows.Scan(&a.Attribute, &a.BucketStart, &min, &max, &avg, &sum, &count); err != nil {
+			return twins.StatesPage{}, err
+		}
+		a.Min, a.Max, a.Avg, a.Sum, a.Count = min.Float64, max.Float64, avg.Float64, sum.Float64, uint64(count.Int64)
+		aggs = append(aggs, a)
+	}
+
+	return twins.StatesPage{Aggregates: aggs}, nil
+}
+
+// aggregateColumns renders the min/max/avg/sum/count SELECT expressions,
+// substituting NULL for any aggregation not present in aggs so that
+// retrieveAggregates only ever computes what the caller actually asked for.
+func aggregateColumns(aggs []twins.Aggregation) string {
+	requested := make(map[twins.Aggregation]bool, len(aggs))
+	for _, a := range aggs {
+		requested[a] = true
+	}
+
+	col := func(agg twins.Aggregation, expr string) string {
+		if !requested[agg] {
+			return "NULL"
+		}
+		return expr
+	}
+
+	return fmt.Sprintf("%s AS min, %s AS max, %s AS avg, %s AS sum, %s AS count",
+		col(twins.AggMin, "min(attr.value::float8)"),
+		col(twins.AggMax, "max(attr.value::float8)"),
+		col(twins.AggAvg, "avg(attr.value::float8)"),
+		col(twins.AggSum, "sum(attr.value::float8)"),
+		col(twins.AggCount, "count(*)"),
+	)
+}
+
+func (sr stateRepository) total(ctx context.Context, q string, params map[string]interface{}) (uint64, error) {
+	rows, err := sr.db.NamedQueryContext(ctx, q, params)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var total uint64
+	if rows.Next() {
+		if err := rows.Scan(&total); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
+
+// buildStateFilter renders the WHERE clause and named parameters shared by
+// both the raw and aggregated queries.
+func buildStateFilter(twinID string, sf twins.StateFilter) (string, map[string]interface{}) {
+	clauses := []string{"twin_id = :twin_id"}
+	params := map[string]interface{}{"twin_id": twinID}
+
+	if sf.From > 0 {
+		clauses = append(clauses, "created >= :from")
+		params["from"] = time.Unix(0, sf.From)
+	}
+	if sf.To > 0 {
+		clauses = append(clauses, "created < :to")
+		params["to"] = time.Unix(0, sf.To)
+	}
+	if sf.Definition >= 0 {
+		clauses = append(clauses, "definition = :definition")
+		params["definition"] = sf.Definition
+	}
+
+	return "WHERE " + strings.Join(clauses, " AND "), params
+}
+
+// withAttributeFilter appends an attr.key = ANY(:attrs) clause bound against
+// params, matching the named-parameter style buildStateFilter already uses
+// instead of concatenating attrs into the query text.
+func withAttributeFilter(whereClause string, attrs []string, params map[string]interface{}) string {
+	if len(attrs) == 0 {
+		return whereClause
+	}
+
+	params["attrs"] = pq.Array(attrs)
+
+	return whereClause + " AND attr.key = ANY(:attrs)"
+}
+
+type dbState struct {
+	ID         uint64    `db:"id"`
+	TwinID     string    `db:"twin_id"`
+	Owner      string    `db:"owner"`
+	Definition int       `db:"definition"`
+	Created    time.Time `db:"created"`
+	Payload    []byte    `db:"payload"`
+}
+
+func toDBState(st twins.State) (dbState, error) {
+	payload, err := json.Marshal(st.Payload)
+	if err != nil {
+		return dbState{}, err
+	}
+
+	return dbState{
+		ID:         st.ID,
+		TwinID:     st.TwinID,
+		Owner:      st.Owner,
+		Definition: st.Definition,
+		Created:    st.Created,
+		Payload:    payload,
+	}, nil
+}
+
+func toState(dbSt dbState) (twins.State, error) {
+	var payload map[string]interface{}
+	if len(dbSt.Payload) > 0 {
+		if err := json.Unmarshal(dbSt.Payload, &payload); err != nil {
+			return twins.State{}, err
+		}
+	}
+
+	return twins.State{
+		ID:         dbSt.ID,
+		TwinID:     dbSt.TwinID,
+		Owner:      dbSt.Owner,
+		Definition: dbSt.Definition,
+		Created:    dbSt.Created,
+		Payload:    payload,
+	}, nil
+}
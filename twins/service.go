@@ -11,7 +11,6 @@ import (
 	"time"
 
 	"github.com/mainflux/mainflux"
-	"github.com/mainflux/mainflux/twins/mqtt"
 	"github.com/mainflux/senml"
 	"github.com/nats-io/go-nats"
 )
@@ -51,8 +50,9 @@ type Service interface {
 	ListTwins(context.Context, string, uint64, uint64, string, Metadata) (TwinsPage, error)
 
 	// ListStates retrieves data about subset of states that belongs to the
-	// twin identified by the id.
-	ListStates(context.Context, string, uint64, uint64, string) (StatesPage, error)
+	// twin identified by the id, optionally narrowed by time range,
+	// definition and attributes, and aggregated per StateFilter.
+	ListStates(context.Context, string, uint64, uint64, string, StateFilter) (StatesPage, error)
 
 	// SaveState persists state into database
 	SaveState(*mainflux.Message) error
@@ -65,46 +65,99 @@ type Service interface {
 	// RemoveTwin removes the twin identified with the provided ID, that
 	// belongs to the user identified by the provided key.
 	RemoveTwin(context.Context, string, string) error
+
+	// ListDefinitions retrieves a subset of the definitions recorded for
+	// the twin identified by the provided ID.
+	ListDefinitions(ctx context.Context, token, twinID string, offset, limit uint64) (DefinitionsPage, error)
+
+	// ViewDefinition retrieves a single definition version of the twin
+	// identified by the provided ID.
+	ViewDefinition(ctx context.Context, token, twinID string, defID int) (Definition, error)
+
+	// RollbackDefinition makes the definition version identified by defID
+	// current again, by appending a copy of it as a new definition and
+	// bumping the twin's revision.
+	RollbackDefinition(ctx context.Context, token, twinID string, defID int) (Twin, error)
+
+	// CreateSubscription registers a webhook that will be POSTed to
+	// whenever eventType (or any event, for "*") fires for the twin
+	// identified by twinID.
+	CreateSubscription(ctx context.Context, token, twinID, eventType, url, secret string) (Subscription, error)
+
+	// ListSubscriptions retrieves a subset of the webhook subscriptions
+	// registered for the twin identified by twinID.
+	ListSubscriptions(ctx context.Context, token, twinID string, offset, limit uint64) (SubscriptionsPage, error)
+
+	// RemoveSubscription removes the subscription identified by subID.
+	RemoveSubscription(ctx context.Context, token, twinID, subID string) error
 }
 
 var crudOp = map[string]string{
-	"createSucc": "create/success",
-	"createFail": "create/failure",
-	"updateSucc": "update/success",
-	"updateFail": "update/failure",
-	"getSucc":    "get/success",
-	"getFail":    "get/failure",
-	"removeSucc": "remove/success",
-	"removeFail": "remove/failure",
+	"createSucc":           "create/success",
+	"createFail":           "create/failure",
+	"updateSucc":           "update/success",
+	"updateFail":           "update/failure",
+	"getSucc":              "get/success",
+	"getFail":              "get/failure",
+	"removeSucc":           "remove/success",
+	"removeFail":           "remove/failure",
+	"definitionCreateSucc": "definition/create/success",
+	"definitionCreateFail": "definition/create/failure",
+	"definitionGetSucc":    "definition/get/success",
+	"definitionGetFail":    "definition/get/failure",
+	"subscribeSucc":        "subscription/create/success",
+	"subscribeFail":        "subscription/create/failure",
+	"unsubscribeSucc":      "subscription/remove/success",
+	"unsubscribeFail":      "subscription/remove/failure",
+	"stateSucc":            "state/success",
+	"stateFail":            "state/failure",
+	"stateInvalid":         "state/invalid",
 }
 
+// defaultSaveStateTimeout bounds how long a single SaveState call may spend
+// in storage calls when New is given a non-positive saveStateTimeout.
+const defaultSaveStateTimeout = 5 * time.Second
+
 type twinsService struct {
-	natsClient *nats.Conn
-	mqttClient mqtt.Mqtt
-	auth       mainflux.AuthNServiceClient
-	twins      TwinRepository
-	states     StateRepository
-	idp        IdentityProvider
+	ctx              context.Context
+	natsClient       *nats.Conn
+	notifiers        NotifierSet
+	subs             SubscriptionRepository
+	auth             mainflux.AuthNServiceClient
+	twins            TwinRepository
+	states           StateRepository
+	idp              IdentityProvider
+	saveStateTimeout time.Duration
 }
 
 var _ Service = (*twinsService)(nil)
 
-// New instantiates the twins service implementation.
-func New(nc *nats.Conn, mc mqtt.Mqtt, auth mainflux.AuthNServiceClient, twins TwinRepository, sr StateRepository, idp IdentityProvider) Service {
+// New instantiates the twins service implementation. ctx is the service's
+// root context: it is cancelled at shutdown and every SaveState call derives
+// its own deadline from it, bounded by saveStateTimeout (defaultSaveStateTimeout
+// when zero), so a wedged store can no longer stall the NATS consumer forever.
+func New(ctx context.Context, nc *nats.Conn, notifiers NotifierSet, auth mainflux.AuthNServiceClient, twins TwinRepository, sr StateRepository, subs SubscriptionRepository, idp IdentityProvider, saveStateTimeout time.Duration) Service {
+	if saveStateTimeout <= 0 {
+		saveStateTimeout = defaultSaveStateTimeout
+	}
+
 	return &twinsService{
-		natsClient: nc,
-		mqttClient: mc,
-		auth:       auth,
-		twins:      twins,
-		states:     sr,
-		idp:        idp,
+		ctx:              ctx,
+		natsClient:       nc,
+		notifiers:        notifiers,
+		subs:             subs,
+		auth:             auth,
+		twins:            twins,
+		states:           sr,
+		idp:              idp,
+		saveStateTimeout: saveStateTimeout,
 	}
 }
 
 func (ts *twinsService) AddTwin(ctx context.Context, token string, twin Twin, def Definition) (tw Twin, err error) {
 	var id string
 	var b []byte
-	defer ts.mqttClient.Publish(&id, &err, crudOp["createSucc"], crudOp["createFail"], &b)
+	defer ts.notifiers.Publish(&id, &err, crudOp["createSucc"], crudOp["createFail"], &b)
 
 	res, err := ts.auth.Identify(ctx, &mainflux.Token{Value: token})
 	if err != nil {
@@ -125,6 +178,9 @@ func (ts *twinsService) AddTwin(ctx context.Context, token string, twin Twin, de
 		def = Definition{}
 		def.Attributes = make(map[string]Attribute)
 	}
+	if err = validateDefinition(def); err != nil {
+		return Twin{}, err
+	}
 	def.Created = time.Now()
 	def.ID = 0
 	twin.Definitions = append(twin.Definitions, def)
@@ -143,7 +199,7 @@ func (ts *twinsService) AddTwin(ctx context.Context, token string, twin Twin, de
 func (ts *twinsService) UpdateTwin(ctx context.Context, token string, twin Twin, def Definition) (err error) {
 	var b []byte
 	var id string
-	defer ts.mqttClient.Publish(&id, &err, crudOp["updateSucc"], crudOp["updateFail"], &b)
+	defer ts.notifiers.Publish(&id, &err, crudOp["updateSucc"], crudOp["updateFail"], &b)
 
 	_, err = ts.auth.Identify(ctx, &mainflux.Token{Value: token})
 	if err != nil {
@@ -166,6 +222,9 @@ func (ts *twinsService) UpdateTwin(ctx context.Context, token string, twin Twin,
 	}
 
 	if len(def.Attributes) > 0 {
+		if err = validateDefinition(def); err != nil {
+			return err
+		}
 		def.Created = time.Now()
 		def.ID = tw.Definitions[len(tw.Definitions)-1].ID + 1
 		tw.Definitions = append(tw.Definitions, def)
@@ -187,7 +246,7 @@ func (ts *twinsService) UpdateTwin(ctx context.Context, token string, twin Twin,
 
 func (ts *twinsService) ViewTwin(ctx context.Context, token, id string) (tw Twin, err error) {
 	var b []byte
-	defer ts.mqttClient.Publish(&id, &err, crudOp["getSucc"], crudOp["getFail"], &b)
+	defer ts.notifiers.Publish(&id, &err, crudOp["getSucc"], crudOp["getFail"], &b)
 
 	_, err = ts.auth.Identify(ctx, &mainflux.Token{Value: token})
 	if err != nil {
@@ -215,7 +274,7 @@ func (ts *twinsService) ViewTwinByThing(ctx context.Context, token, thingid stri
 
 func (ts *twinsService) RemoveTwin(ctx context.Context, token, id string) (err error) {
 	var b []byte
-	defer ts.mqttClient.Publish(&id, &err, crudOp["removeSucc"], crudOp["removeFail"], &b)
+	defer ts.notifiers.Publish(&id, &err, crudOp["removeSucc"], crudOp["removeFail"], &b)
 
 	_, err = ts.auth.Identify(ctx, &mainflux.Token{Value: token})
 	if err != nil {
@@ -242,29 +301,33 @@ func (ts *twinsService) SaveState(msg *mainflux.Message) error {
 	var b []byte
 	var id string
 	var err error
-	defer ts.mqttClient.Publish(&id, &err, crudOp["stateSucc"], crudOp["stateFail"], &b)
+	defer ts.notifiers.Publish(&id, &err, crudOp["stateSucc"], crudOp["stateFail"], &b)
+
+	ctx, cancel := context.WithTimeout(ts.ctx, ts.saveStateTimeout)
+	defer cancel()
 
-	tw, err := ts.twins.RetrieveByThing(context.TODO(), msg.Publisher)
+	tw, err := ts.twins.RetrieveByThing(ctx, msg.Publisher)
 	if err != nil {
-		return fmt.Errorf("Retrieving twin for %s failed: %s", msg.Publisher, err)
+		return fmt.Errorf("Retrieving twin for %s failed: %w", msg.Publisher, err)
 	}
 
 	var recs []senml.Record
 	if err := json.Unmarshal(msg.Payload, &recs); err != nil {
-		return fmt.Errorf("Unmarshal payload for %s failed: %s", msg.Publisher, err)
+		return fmt.Errorf("Unmarshal payload for %s failed: %w", msg.Publisher, err)
 	}
 
-	st, err := ts.states.RetrieveLast(context.TODO(), tw.ID)
+	last, err := ts.states.RetrieveLast(ctx, tw.ID)
 	if err != nil {
-		return fmt.Errorf("Retrieve last state for %s failed: %s", msg.Publisher, err)
+		return fmt.Errorf("Retrieve last state for %s failed: %w", msg.Publisher, err)
 	}
 
-	if save := prepareState(&st, &tw, recs, msg); !save {
-		return nil
-	}
+	states, invalid := prepareStates(last.ID, &tw, recs, msg)
+	ts.reportInvalidRecords(tw.ID, invalid)
 
-	if err := ts.states.Save(context.TODO(), st); err != nil {
-		return fmt.Errorf("Updating state for %s failed: %s", msg.Publisher, err)
+	for _, st := range states {
+		if err := ts.states.Save(ctx, st); err != nil {
+			return fmt.Errorf("Updating state for %s failed: %w", msg.Publisher, err)
+		}
 	}
 
 	id = msg.Publisher
@@ -273,36 +336,312 @@ func (ts *twinsService) SaveState(msg *mainflux.Message) error {
 	return nil
 }
 
-func (ts *twinsService) ListStates(ctx context.Context, token string, offset uint64, limit uint64, id string) (StatesPage, error) {
+// reportInvalidRecords notifies subscribers about every SenML record that
+// failed its attribute's Schema, so integrators can see garbage input even
+// when StrictValidation left it out of the persisted state.
+func (ts *twinsService) reportInvalidRecords(twinID string, invalid []InvalidRecord) {
+	for _, iv := range invalid {
+		id := twinID
+		b, err := json.Marshal(iv)
+		ts.notifiers.Publish(&id, &err, crudOp["stateInvalid"], crudOp["stateInvalid"], &b)
+	}
+}
+
+func (ts *twinsService) ListStates(ctx context.Context, token string, offset uint64, limit uint64, id string, sf StateFilter) (StatesPage, error) {
 	_, err := ts.auth.Identify(ctx, &mainflux.Token{Value: token})
 	if err != nil {
 		return StatesPage{}, ErrUnauthorizedAccess
 	}
 
-	return ts.states.RetrieveAll(ctx, offset, limit, id)
+	return ts.states.RetrieveAll(ctx, offset, limit, id, sf)
+}
+
+func (ts *twinsService) ListDefinitions(ctx context.Context, token, twinID string, offset, limit uint64) (dp DefinitionsPage, err error) {
+	var b []byte
+	id := twinID
+	defer ts.notifiers.Publish(&id, &err, crudOp["definitionGetSucc"], crudOp["definitionGetFail"], &b)
+
+	tw, err := ts.ownsTwin(ctx, token, twinID)
+	if err != nil {
+		return DefinitionsPage{}, err
+	}
+
+	total := uint64(len(tw.Definitions))
+	if offset >= total {
+		return DefinitionsPage{Total: total, Offset: offset, Limit: limit}, nil
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	return DefinitionsPage{
+		Total:       total,
+		Offset:      offset,
+		Limit:       limit,
+		Definitions: tw.Definitions[offset:end],
+	}, nil
+}
+
+func (ts *twinsService) ViewDefinition(ctx context.Context, token, twinID string, defID int) (Definition, error) {
+	tw, err := ts.ownsTwin(ctx, token, twinID)
+	if err != nil {
+		return Definition{}, err
+	}
+
+	return findDefinition(tw, defID)
+}
+
+func (ts *twinsService) RollbackDefinition(ctx context.Context, token, twinID string, defID int) (tw Twin, err error) {
+	var b []byte
+	id := twinID
+	defer ts.notifiers.Publish(&id, &err, crudOp["definitionCreateSucc"], crudOp["definitionCreateFail"], &b)
+
+	tw, err = ts.ownsTwin(ctx, token, twinID)
+	if err != nil {
+		return Twin{}, err
+	}
+
+	old, err := findDefinition(tw, defID)
+	if err != nil {
+		return Twin{}, err
+	}
+
+	attrs := make(map[string]Attribute, len(old.Attributes))
+	for name, a := range old.Attributes {
+		attrs[name] = a
+	}
+
+	rollback := Definition{
+		ID:         tw.Definitions[len(tw.Definitions)-1].ID + 1,
+		Created:    time.Now(),
+		Attributes: attrs,
+	}
+	tw.Definitions = append(tw.Definitions, rollback)
+	tw.Revision++
+	tw.Updated = time.Now()
+
+	if err = ts.twins.Update(ctx, tw); err != nil {
+		return Twin{}, err
+	}
+
+	b, err = json.Marshal(tw)
+
+	return tw, nil
+}
+
+// findDefinition returns the definition with the given ID out of tw's
+// definition history, or ErrNotFound if no such version was ever recorded.
+func findDefinition(tw Twin, defID int) (Definition, error) {
+	for _, def := range tw.Definitions {
+		if def.ID == defID {
+			return def, nil
+		}
+	}
+
+	return Definition{}, ErrNotFound
+}
+
+func (ts *twinsService) CreateSubscription(ctx context.Context, token, twinID, eventType, url, secret string) (sub Subscription, err error) {
+	var b []byte
+	id := twinID
+	defer ts.notifiers.Publish(&id, &err, crudOp["subscribeSucc"], crudOp["subscribeFail"], &b)
+
+	if _, err = ts.ownsTwin(ctx, token, twinID); err != nil {
+		return Subscription{}, err
+	}
+
+	subID, err := ts.idp.ID()
+	if err != nil {
+		return Subscription{}, err
+	}
+
+	sub = Subscription{
+		ID:        subID,
+		TwinID:    twinID,
+		EventType: eventType,
+		URL:       url,
+		Secret:    secret,
+		Created:   time.Now(),
+	}
+
+	if _, err = ts.subs.Save(ctx, sub); err != nil {
+		return Subscription{}, err
+	}
+
+	b, err = json.Marshal(sub)
+
+	return sub, nil
+}
+
+func (ts *twinsService) ListSubscriptions(ctx context.Context, token, twinID string, offset, limit uint64) (SubscriptionsPage, error) {
+	if _, err := ts.ownsTwin(ctx, token, twinID); err != nil {
+		return SubscriptionsPage{}, err
+	}
+
+	return ts.subs.RetrieveAll(ctx, twinID, offset, limit)
+}
+
+func (ts *twinsService) RemoveSubscription(ctx context.Context, token, twinID, subID string) (err error) {
+	var b []byte
+	id := twinID
+	defer ts.notifiers.Publish(&id, &err, crudOp["unsubscribeSucc"], crudOp["unsubscribeFail"], &b)
+
+	if _, err = ts.ownsTwin(ctx, token, twinID); err != nil {
+		return err
+	}
+
+	subs, err := ts.subs.RetrieveByTwin(ctx, twinID)
+	if err != nil {
+		return err
+	}
+
+	belongs := false
+	for _, s := range subs {
+		if s.ID == subID {
+			belongs = true
+			break
+		}
+	}
+	if !belongs {
+		return ErrNotFound
+	}
+
+	return ts.subs.Remove(ctx, subID)
+}
+
+// ownsTwin identifies token and checks that the resulting user owns the
+// twin identified by twinID, returning ErrUnauthorizedAccess otherwise.
+func (ts *twinsService) ownsTwin(ctx context.Context, token, twinID string) (Twin, error) {
+	res, err := ts.auth.Identify(ctx, &mainflux.Token{Value: token})
+	if err != nil {
+		return Twin{}, ErrUnauthorizedAccess
+	}
+
+	tw, err := ts.twins.RetrieveByID(ctx, twinID)
+	if err != nil {
+		return Twin{}, err
+	}
+
+	if tw.Owner != res.GetValue() {
+		return Twin{}, ErrUnauthorizedAccess
+	}
+
+	return tw, nil
 }
 
-func prepareState(st *State, tw *Twin, recs []senml.Record, msg *mainflux.Message) bool {
+// prepareStates groups recs by their SenML timestamp (BaseTime plus the
+// record's own Time), and builds one State per distinct timestamp so that a
+// single publish carrying several attributes (e.g. temperature and
+// humidity sampled together) persists as a single state rather than one per
+// message. lastID is the ID of the most recently saved state for this
+// twin; returned states are numbered consecutively from there.
+func prepareStates(lastID uint64, tw *Twin, recs []senml.Record, msg *mainflux.Message) ([]State, []InvalidRecord) {
 	def := tw.Definitions[len(tw.Definitions)-1]
-	st.TwinID = tw.ID
-	st.ID++
-	st.Created = time.Now()
-	st.Definition = def.ID
-	if st.Payload == nil {
-		st.Payload = make(map[string]interface{})
+
+	var order []float64
+	clusters := make(map[float64][]senml.Record)
+	var baseTime float64
+	for _, r := range recs {
+		if r.BaseTime != 0 {
+			baseTime = r.BaseTime
+		}
+		t := baseTime + r.Time
+		if _, ok := clusters[t]; !ok {
+			order = append(order, t)
+		}
+		clusters[t] = append(clusters[t], r)
 	}
 
-	save := false
-	for k, a := range def.Attributes {
-		if !a.PersistState {
+	var states []State
+	var invalid []InvalidRecord
+	for _, t := range order {
+		st := State{
+			TwinID:     tw.ID,
+			Owner:      tw.Owner,
+			Definition: def.ID,
+			Created:    recordTime(t),
+			Payload:    make(map[string]interface{}),
+		}
+
+		for _, r := range clusters[t] {
+			for k, a := range def.Attributes {
+				if !a.PersistState || !attributeMatches(a, r, msg) {
+					continue
+				}
+
+				if err := validateValue(a, r); err != nil {
+					invalid = append(invalid, InvalidRecord{Attribute: k, Record: r, Reason: err.Error()})
+					if a.StrictValidation {
+						continue
+					}
+				}
+
+				st.Payload[k] = recordValue(a, r)
+			}
+		}
+
+		if len(st.Payload) == 0 {
 			continue
 		}
-		if a.Channel == msg.Channel && a.Subtopic == msg.Subtopic {
-			st.Payload[k] = recs[0].Value
-			save = true
-			break
+
+		lastID++
+		st.ID = lastID
+		states = append(states, st)
+	}
+
+	return states, invalid
+}
+
+// attributeMatches reports whether a matches the channel/subtopic the
+// message was published on and, when a.Name is set, also the record's
+// resolved SenML name (BaseName+Name).
+func attributeMatches(a Attribute, r senml.Record, msg *mainflux.Message) bool {
+	if a.Channel != msg.Channel || a.Subtopic != msg.Subtopic {
+		return false
+	}
+
+	if a.Name == "" {
+		return true
+	}
+
+	return r.BaseName+r.Name == a.Name
+}
+
+// recordValue extracts the SenML value that matches a's Schema type: a
+// numeric attribute reads r.Value, while "string"/"bool" attributes read
+// r.StringValue/r.BoolValue instead, since r.Value is nil for those. An
+// untyped attribute (Type == "") predates the Type field or never opted
+// into it, so it infers the shape from whichever field r actually set.
+func recordValue(a Attribute, r senml.Record) interface{} {
+	switch a.Type {
+	case "string":
+		return r.StringValue
+	case "bool":
+		return r.BoolValue
+	case "number":
+		return r.Value
+	default:
+		switch {
+		case r.Value != nil:
+			return r.Value
+		case r.StringValue != nil:
+			return r.StringValue
+		case r.BoolValue != nil:
+			return r.BoolValue
+		default:
+			return nil
 		}
 	}
+}
+
+// recordTime converts a SenML timestamp (seconds, possibly fractional) to
+// a time.Time, falling back to time.Now() when the record carries none.
+func recordTime(t float64) time.Time {
+	if t == 0 {
+		return time.Now()
+	}
 
-	return save
+	return time.Unix(0, int64(t*float64(time.Second)))
 }
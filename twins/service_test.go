@@ -0,0 +1,238 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package twins_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mainflux/mainflux"
+	"github.com/mainflux/mainflux/twins"
+	"github.com/mainflux/mainflux/twins/mocks"
+	"github.com/mainflux/senml"
+	"github.com/nats-io/go-nats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	thingID      = "thing-1"
+	token        = "token"
+	intruderTok  = "intruder-token"
+	intruderUser = "intruder"
+)
+
+func newService(t *testing.T) (twins.Service, twins.TwinRepository, twins.StateRepository) {
+	twinRepo := mocks.NewTwinRepository()
+	stateRepo := mocks.NewStateRepository()
+	auth := mocks.NewAuthService(map[string]string{token: "owner", intruderTok: intruderUser})
+	idp := mocks.NewIdentityProvider()
+	mc := mocks.NewMqttClient()
+
+	svc := twins.New(context.Background(), &nats.Conn{}, twins.NotifierSet{mc}, auth, twinRepo, stateRepo, mocks.NewSubscriptionRepository(), idp, time.Second)
+
+	def := twins.Definition{
+		Attributes: map[string]twins.Attribute{
+			"temperature": {
+				Name:         "temperature",
+				Channel:      "channel-1",
+				Subtopic:     "engine",
+				PersistState: true,
+			},
+			"humidity": {
+				Name:         "humidity",
+				Channel:      "channel-1",
+				Subtopic:     "chassis",
+				PersistState: true,
+			},
+		},
+	}
+
+	_, err := svc.AddTwin(context.Background(), token, twins.Twin{ThingID: thingID}, def)
+	require.Nil(t, err, "adding twin must succeed")
+
+	return svc, twinRepo, stateRepo
+}
+
+func senmlPayload(t *testing.T, name string, value float64) []byte {
+	recs := []senml.Record{{Name: name, Value: &value}}
+	b, err := json.Marshal(recs)
+	require.Nil(t, err, "marshaling senml must succeed")
+	return b
+}
+
+func TestSaveStatePersistsMultipleAttributesWithDistinctSubtopics(t *testing.T) {
+	svc, _, stateRepo := newService(t)
+
+	msgEngine := &mainflux.Message{
+		Publisher: thingID,
+		Channel:   "channel-1",
+		Subtopic:  "engine",
+		Payload:   senmlPayload(t, "temperature", 90.5),
+	}
+	msgChassis := &mainflux.Message{
+		Publisher: thingID,
+		Channel:   "channel-1",
+		Subtopic:  "chassis",
+		Payload:   senmlPayload(t, "humidity", 45.0),
+	}
+
+	err := svc.SaveState(msgEngine)
+	require.Nil(t, err, "saving state for engine subtopic must succeed")
+
+	err = svc.SaveState(msgChassis)
+	require.Nil(t, err, "saving state for chassis subtopic must succeed")
+
+	mock, ok := stateRepo.(interface{ AllStates(string) []twins.State })
+	require.True(t, ok, "state repository mock must expose AllStates")
+
+	tw, err := svc.ViewTwinByThing(context.Background(), token, thingID)
+	require.Nil(t, err, "viewing twin by thing must succeed")
+
+	states := mock.AllStates(tw.ID)
+	assert.Len(t, states, 2, "one state should have been saved per subtopic")
+	assert.Equal(t, 90.5, *states[0].Payload["temperature"].(*float64))
+	assert.Equal(t, 45.0, *states[1].Payload["humidity"].(*float64))
+}
+
+func TestSaveStatePersistsAllMatchingAttributesInOneBatch(t *testing.T) {
+	twinRepo := mocks.NewTwinRepository()
+	stateRepo := mocks.NewStateRepository()
+	auth := mocks.NewAuthService(map[string]string{token: "owner"})
+	idp := mocks.NewIdentityProvider()
+	mc := mocks.NewMqttClient()
+	svc := twins.New(context.Background(), &nats.Conn{}, twins.NotifierSet{mc}, auth, twinRepo, stateRepo, mocks.NewSubscriptionRepository(), idp, time.Second)
+
+	// Both attributes share the same channel/subtopic so a single publish
+	// can match more than one of them; the fix under test is that every
+	// matching attribute is written, not just the first one found.
+	def := twins.Definition{
+		Attributes: map[string]twins.Attribute{
+			"temperature": {Name: "temperature", Channel: "channel-1", Subtopic: "combined", PersistState: true},
+			"humidity":    {Name: "humidity", Channel: "channel-1", Subtopic: "combined", PersistState: true},
+		},
+	}
+	_, err := svc.AddTwin(context.Background(), token, twins.Twin{ThingID: thingID}, def)
+	require.Nil(t, err, "adding twin must succeed")
+
+	value1, value2 := 91.0, 46.5
+	recs := []senml.Record{
+		{Name: "temperature", Value: &value1},
+		{Name: "humidity", Value: &value2},
+	}
+	b, err := json.Marshal(recs)
+	require.Nil(t, err, "marshaling senml must succeed")
+
+	msg := &mainflux.Message{
+		Publisher: thingID,
+		Channel:   "channel-1",
+		Subtopic:  "combined",
+		Payload:   b,
+	}
+
+	err = svc.SaveState(msg)
+	require.Nil(t, err, "saving state must succeed")
+
+	tw, err := svc.ViewTwinByThing(context.Background(), token, thingID)
+	require.Nil(t, err, "viewing twin by thing must succeed")
+
+	mock := stateRepo.(interface{ AllStates(string) []twins.State })
+	states := mock.AllStates(tw.ID)
+	require.Len(t, states, 1, "records sharing a timestamp must collapse into one state")
+	assert.Equal(t, 91.0, *states[0].Payload["temperature"].(*float64))
+	assert.Equal(t, 46.5, *states[0].Payload["humidity"].(*float64))
+}
+
+func TestListStatesFiltersByAttribute(t *testing.T) {
+	svc, _, _ := newService(t)
+
+	value1, value2 := 91.0, 46.5
+	recs := []senml.Record{
+		{Name: "temperature", Value: &value1},
+		{Name: "humidity", Value: &value2},
+	}
+	b, err := json.Marshal(recs)
+	require.Nil(t, err, "marshaling senml must succeed")
+
+	err = svc.SaveState(&mainflux.Message{
+		Publisher: thingID,
+		Channel:   "channel-1",
+		Subtopic:  "engine",
+		Payload:   b,
+	})
+	require.Nil(t, err, "saving state must succeed")
+
+	err = svc.SaveState(&mainflux.Message{
+		Publisher: thingID,
+		Channel:   "channel-1",
+		Subtopic:  "chassis",
+		Payload:   senmlPayload(t, "humidity", 46.5),
+	})
+	require.Nil(t, err, "saving state must succeed")
+
+	tw, err := svc.ViewTwinByThing(context.Background(), token, thingID)
+	require.Nil(t, err, "viewing twin by thing must succeed")
+
+	page, err := svc.ListStates(context.Background(), token, 0, 10, tw.ID, twins.StateFilter{
+		Definition: -1,
+		Attributes: []string{"temperature"},
+	})
+	require.Nil(t, err, "listing states must succeed")
+	require.Len(t, page.States, 2)
+	assert.Contains(t, page.States[0].Payload, "temperature")
+	for _, st := range page.States {
+		assert.NotContains(t, st.Payload, "humidity", "unrequested attributes must be filtered out")
+	}
+}
+
+func TestListDefinitionsRejectsNonOwner(t *testing.T) {
+	svc, _, _ := newService(t)
+
+	tw, err := svc.ViewTwinByThing(context.Background(), token, thingID)
+	require.Nil(t, err, "viewing twin by thing must succeed")
+
+	_, err = svc.ListDefinitions(context.Background(), intruderTok, tw.ID, 0, 10)
+	assert.Equal(t, twins.ErrUnauthorizedAccess, err, "a non-owner must not be able to list another owner's definitions")
+}
+
+func TestViewDefinitionRejectsNonOwner(t *testing.T) {
+	svc, _, _ := newService(t)
+
+	tw, err := svc.ViewTwinByThing(context.Background(), token, thingID)
+	require.Nil(t, err, "viewing twin by thing must succeed")
+
+	_, err = svc.ViewDefinition(context.Background(), intruderTok, tw.ID, 0)
+	assert.Equal(t, twins.ErrUnauthorizedAccess, err, "a non-owner must not be able to view another owner's definition")
+}
+
+func TestRollbackDefinitionRejectsNonOwner(t *testing.T) {
+	svc, _, _ := newService(t)
+
+	tw, err := svc.ViewTwinByThing(context.Background(), token, thingID)
+	require.Nil(t, err, "viewing twin by thing must succeed")
+
+	_, err = svc.RollbackDefinition(context.Background(), intruderTok, tw.ID, 0)
+	assert.Equal(t, twins.ErrUnauthorizedAccess, err, "a non-owner must not be able to roll back another owner's definition")
+}
+
+func TestSubscriptionMethodsRejectNonOwner(t *testing.T) {
+	svc, _, _ := newService(t)
+
+	tw, err := svc.ViewTwinByThing(context.Background(), token, thingID)
+	require.Nil(t, err, "viewing twin by thing must succeed")
+
+	_, err = svc.CreateSubscription(context.Background(), intruderTok, tw.ID, "*", "http://example.com", "secret")
+	assert.Equal(t, twins.ErrUnauthorizedAccess, err, "a non-owner must not be able to subscribe to another owner's twin")
+
+	_, err = svc.ListSubscriptions(context.Background(), intruderTok, tw.ID, 0, 10)
+	assert.Equal(t, twins.ErrUnauthorizedAccess, err, "a non-owner must not be able to list another owner's subscriptions")
+
+	sub, err := svc.CreateSubscription(context.Background(), token, tw.ID, "*", "http://example.com", "secret")
+	require.Nil(t, err, "the owner's own subscription must succeed")
+
+	err = svc.RemoveSubscription(context.Background(), intruderTok, tw.ID, sub.ID)
+	assert.Equal(t, twins.ErrUnauthorizedAccess, err, "a non-owner must not be able to remove another owner's subscription")
+}
@@ -0,0 +1,80 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package twins
+
+import (
+	"context"
+	"time"
+)
+
+// State represents a snapshot of a twin's attributes at a point in time,
+// built from the SenML records published on the twin's channel.
+type State struct {
+	ID         uint64
+	TwinID     string
+	Owner      string
+	Definition int
+	Created    time.Time
+	Payload    map[string]interface{}
+}
+
+// Aggregation enumerates the supported server-side aggregation functions
+// that can be computed per attribute over a bucket of states.
+type Aggregation string
+
+const (
+	AggMin   Aggregation = "min"
+	AggMax   Aggregation = "max"
+	AggAvg   Aggregation = "avg"
+	AggSum   Aggregation = "sum"
+	AggCount Aggregation = "count"
+)
+
+// StateFilter narrows down ListStates/RetrieveAll queries to a time window,
+// a specific definition revision and a set of attributes, optionally
+// requesting aggregation over fixed-size buckets instead of raw payloads.
+type StateFilter struct {
+	From         int64         // Unix nanoseconds, inclusive; zero means unbounded.
+	To           int64         // Unix nanoseconds, exclusive; zero means unbounded.
+	Definition   int           // restricts states to a single definition revision; negative means any.
+	Attributes   []string      // attribute names to include; empty means all.
+	Aggregations []Aggregation // when non-empty, results are returned as AttributeAggregates.
+	BucketSize   time.Duration // bucket width used when Aggregations is set.
+}
+
+// AttributeAggregate holds the computed aggregate values for a single
+// attribute over a single bucket of the queried time range.
+type AttributeAggregate struct {
+	Attribute   string
+	BucketStart time.Time
+	Min         float64
+	Max         float64
+	Avg         float64
+	Sum         float64
+	Count       uint64
+}
+
+// StatesPage contains a page of raw states, or, when a StateFilter with
+// Aggregations was supplied, a page of per-attribute aggregates instead.
+type StatesPage struct {
+	Total      uint64
+	Offset     uint64
+	Limit      uint64
+	States     []State
+	Aggregates []AttributeAggregate
+}
+
+// StateRepository specifies a state persistence API.
+type StateRepository interface {
+	// Save persists the state. Successive Save calls are expected to save
+	// new state.
+	Save(context.Context, State) error
+
+	// RetrieveAll retrieves the subset of states related to twinID specified
+	// by offset and limit, narrowed and/or aggregated according to filter.
+	RetrieveAll(ctx context.Context, offset, limit uint64, twinID string, filter StateFilter) (StatesPage, error)
+
+	// RetrieveLast retrieves the last state related to twinID.
+	RetrieveLast(context.Context, string) (State, error)
+}
@@ -0,0 +1,53 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package twins
+
+import (
+	"context"
+	"time"
+)
+
+// Subscription is a webhook registration for twin CRUD and state events.
+// EventType is one of the crudOp values (e.g. "create/success",
+// "state/success") or "*" to receive every event for the twin.
+type Subscription struct {
+	ID        string
+	TwinID    string
+	Owner     string
+	EventType string
+	URL       string
+	Secret    string // used to HMAC-sign the webhook body, empty means unsigned
+	Created   time.Time
+}
+
+// Matches reports whether the subscription should fire for the given twin
+// ID and event type.
+func (s Subscription) Matches(twinID, eventType string) bool {
+	return s.TwinID == twinID && (s.EventType == "*" || s.EventType == eventType)
+}
+
+// SubscriptionsPage contains page related metadata as well as a list of
+// subscriptions that belong to this page.
+type SubscriptionsPage struct {
+	Total         uint64
+	Offset        uint64
+	Limit         uint64
+	Subscriptions []Subscription
+}
+
+// SubscriptionRepository specifies a webhook subscription persistence API.
+type SubscriptionRepository interface {
+	// Save persists the subscription.
+	Save(context.Context, Subscription) (string, error)
+
+	// RetrieveByTwin retrieves every subscription registered for twinID.
+	RetrieveByTwin(ctx context.Context, twinID string) ([]Subscription, error)
+
+	// RetrieveAll retrieves the subset of subscriptions registered for
+	// twinID specified by offset and limit.
+	RetrieveAll(ctx context.Context, twinID string, offset, limit uint64) (SubscriptionsPage, error)
+
+	// Remove removes the subscription having the provided identifier.
+	Remove(ctx context.Context, id string) error
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package twins
+
+import (
+	"context"
+	"time"
+)
+
+// Metadata to be used for mainflux twin or channel for customized
+// describing of particular thing.
+type Metadata map[string]interface{}
+
+// Attribute describes a single SenML record that should be captured into a
+// twin's state, and how it is matched against incoming messages.
+type Attribute struct {
+	Name         string // matched against recs[i].Name or BaseName+Name
+	Channel      string // matched against msg.Channel
+	Subtopic     string // matched against msg.Subtopic
+	PersistState bool
+
+	// Schema narrows the values accepted for this attribute. Type is one
+	// of "number", "string" or "bool"; when empty, a value of any shape is
+	// accepted and Min/Max/Enum are not checked. Min/Max apply to numeric
+	// attributes, Enum restricts the value to a fixed set (of float64 or
+	// string, matching Type), and Unit is informational only.
+	Type             string
+	Unit             string
+	Min              *float64
+	Max              *float64
+	Enum             []interface{}
+	StrictValidation bool // when true, values failing Schema are dropped instead of merely reported
+}
+
+// Definition represents one revision of a twin's attribute mapping. Twins
+// accumulate definitions over time; the last one in Twin.Definitions is the
+// one currently in effect.
+type Definition struct {
+	ID         int
+	Created    time.Time
+	Attributes map[string]Attribute
+}
+
+// Twin is a Mainflux's representation of an app, sensor or actuator
+// connected to a Thing.
+type Twin struct {
+	Owner       string
+	ID          string
+	ThingID     string
+	Name        string
+	Created     time.Time
+	Updated     time.Time
+	Revision    int
+	Definitions []Definition
+	Metadata    Metadata
+}
+
+// TwinsPage contains page related metadata as well as a list of twins that
+// belong to this page.
+type TwinsPage struct {
+	Total  uint64
+	Offset uint64
+	Limit  uint64
+	Twins  []Twin
+}
+
+// DefinitionsPage contains page related metadata as well as a list of a
+// twin's definitions that belong to this page, ordered oldest first so that
+// ID corresponds to position.
+type DefinitionsPage struct {
+	Total       uint64
+	Offset      uint64
+	Limit       uint64
+	Definitions []Definition
+}
+
+// TwinRepository specifies a twin persistence API.
+type TwinRepository interface {
+	// Save persists the twin.
+	Save(context.Context, Twin) (string, error)
+
+	// Update performs an update to the existing twin.
+	Update(context.Context, Twin) error
+
+	// RetrieveByID retrieves the twin having the provided identifier.
+	RetrieveByID(ctx context.Context, twinID string) (Twin, error)
+
+	// RetrieveByThing retrieves the twin related to a given thing ID.
+	RetrieveByThing(ctx context.Context, thingID string) (Twin, error)
+
+	// RetrieveAll retrieves the subset of twins owned by the specified user.
+	RetrieveAll(ctx context.Context, owner string, offset, limit uint64, name string, metadata Metadata) (TwinsPage, error)
+
+	// Remove removes the twin having the provided identifier.
+	Remove(ctx context.Context, twinID string) error
+}
+
+// IdentityProvider specifies an API for generating unique identifiers.
+type IdentityProvider interface {
+	// ID generates the unique identifier.
+	ID() (string, error)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+package twins
+
+import (
+	"fmt"
+
+	"github.com/mainflux/senml"
+)
+
+// InvalidRecord describes a single SenML record that failed Attribute
+// validation, for reporting via the stateInvalid event.
+type InvalidRecord struct {
+	Attribute string       `json:"attribute"`
+	Record    senml.Record `json:"record"`
+	Reason    string       `json:"reason"`
+}
+
+// validateDefinition rejects definitions whose attribute schemas are
+// contradictory (e.g. Min greater than Max, or an unknown Type), before
+// they are ever attached to a twin.
+func validateDefinition(def Definition) error {
+	for name, a := range def.Attributes {
+		if err := validateAttributeSchema(a); err != nil {
+			return fmt.Errorf("%w: attribute %q: %s", ErrMalformedEntity, name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateAttributeSchema(a Attribute) error {
+	switch a.Type {
+	case "", "number", "string", "bool":
+	default:
+		return fmt.Errorf("unknown type %q", a.Type)
+	}
+
+	if a.Min != nil && a.Max != nil && *a.Min > *a.Max {
+		return fmt.Errorf("min (%v) is greater than max (%v)", *a.Min, *a.Max)
+	}
+
+	if (a.Min != nil || a.Max != nil) && a.Type != "" && a.Type != "number" {
+		return fmt.Errorf("min/max only apply to numeric attributes")
+	}
+
+	return nil
+}
+
+// validateValue checks r against a's schema, returning a human-readable
+// reason when it does not conform. An untyped attribute (Type == "")
+// predates the Type field or never opted into it, so it accepts whatever
+// value shape r actually carries instead of assuming numeric - otherwise
+// every pre-existing string/bool publisher would start failing validation
+// the moment Type was introduced.
+func validateValue(a Attribute, r senml.Record) error {
+	switch a.Type {
+	case "number":
+		if r.Value == nil {
+			return fmt.Errorf("expected a numeric value")
+		}
+		v := *r.Value
+		if a.Min != nil && v < *a.Min {
+			return fmt.Errorf("value %v is below min %v", v, *a.Min)
+		}
+		if a.Max != nil && v > *a.Max {
+			return fmt.Errorf("value %v is above max %v", v, *a.Max)
+		}
+		if len(a.Enum) > 0 && !enumContains(a.Enum, v) {
+			return fmt.Errorf("value %v is not in the allowed set", v)
+		}
+
+	case "string":
+		if r.StringValue == nil {
+			return fmt.Errorf("expected a string value")
+		}
+		if len(a.Enum) > 0 && !enumContains(a.Enum, *r.StringValue) {
+			return fmt.Errorf("value %q is not in the allowed set", *r.StringValue)
+		}
+
+	case "bool":
+		if r.BoolValue == nil {
+			return fmt.Errorf("expected a boolean value")
+		}
+
+	default:
+		if r.Value == nil && r.StringValue == nil && r.BoolValue == nil {
+			return fmt.Errorf("record carries no value")
+		}
+	}
+
+	return nil
+}
+
+func enumContains(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,98 @@
+// Copyright (c) Mainflux
+// SPDX-License-Identifier: Apache-2.0
+
+// Package webhook implements a twins.Notifier that delivers twin CRUD and
+// state events to user-registered HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/mainflux/mainflux/twins"
+)
+
+const (
+	maxRetries  = 3
+	backoffBase = 200 * time.Millisecond
+)
+
+var _ twins.Notifier = (*Notifier)(nil)
+
+// Notifier delivers events to every twins.Subscription whose TwinID and
+// EventType match, signing the body with HMAC-SHA256 when the
+// subscription has a Secret, and retrying non-2xx responses with
+// exponential backoff.
+type Notifier struct {
+	subs   twins.SubscriptionRepository
+	client *http.Client
+}
+
+// New creates a webhook Notifier backed by subs.
+func New(subs twins.SubscriptionRepository) *Notifier {
+	return &Notifier{
+		subs:   subs,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (n *Notifier) Publish(id *string, err *error, succOp, failOp string, payload *[]byte) {
+	op := succOp
+	if *err != nil {
+		op = failOp
+	}
+
+	subs, rerr := n.subs.RetrieveByTwin(context.Background(), *id)
+	if rerr != nil {
+		return
+	}
+
+	for _, sub := range subs {
+		if !sub.Matches(*id, op) {
+			continue
+		}
+		go n.deliver(sub, *payload)
+	}
+}
+
+// deliver POSTs payload to sub.URL, retrying non-2xx responses and
+// transport errors up to maxRetries times with exponential backoff. It
+// gives up silently on the last failure: webhook delivery is best-effort
+// and must never block the twin operation that triggered it.
+func (n *Notifier) deliver(sub twins.Subscription, payload []byte) {
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoffBase * time.Duration(1<<uint(attempt-1)))
+		}
+
+		req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(payload))
+		if err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if sub.Secret != "" {
+			req.Header.Set("X-Mainflux-Signature", sign(sub.Secret, payload))
+		}
+
+		resp, err := n.client.Do(req)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return
+		}
+	}
+}
+
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}